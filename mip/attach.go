@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"path/filepath"
+)
+
+// attachment represents a single file to be embedded as its own MIME part.
+type attachment struct {
+	filename    string
+	contentType string
+	data        []byte
+}
+
+// multiFlag collects repeated occurrences of a flag (e.g. -a path1 -a path2)
+// into a slice, implementing flag.Value.
+type multiFlag []string
+
+func (m *multiFlag) String() string {
+	return fmt.Sprint([]string(*m))
+}
+
+func (m *multiFlag) Set(value string) error {
+	*m = append(*m, value)
+	return nil
+}
+
+// detectContentType sniffs data's magic bytes for the formats mip already
+// knows how to recognize, falling back to mime.TypeByExtension based on
+// name's extension, and finally to a generic octet-stream type.
+func detectContentType(data []byte, name string) string {
+	switch {
+	case len(data) >= 8 && bytes.Equal(data[:8], []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A}):
+		return "image/png"
+	case len(data) >= 2 && data[0] == 0xFF && data[1] == 0xD8:
+		return "image/jpeg"
+	case len(data) >= 6 && (string(data[:6]) == "GIF87a" || string(data[:6]) == "GIF89a"):
+		return "image/gif"
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return "image/webp"
+	case len(data) >= 5 && string(data[:5]) == "%PDF-":
+		return "application/pdf"
+	}
+
+	if ext := filepath.Ext(name); ext != "" {
+		if ct := mime.TypeByExtension(ext); ct != "" {
+			return ct
+		}
+	}
+
+	return "application/octet-stream"
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+// rfc2047TokenLimit is the maximum length of a single RFC 2047
+// encoded-word, "=?charset?encoding?encoded-text?=" included.
+const rfc2047TokenLimit = 75
+
+// needsEncoding reports whether s contains any byte outside printable
+// ASCII (0x20-0x7E) and therefore cannot be written into a header
+// verbatim.
+func needsEncoding(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7E {
+			return true
+		}
+	}
+	return false
+}
+
+// isUTF8Continuation reports whether b is a UTF-8 continuation byte
+// (10xxxxxx), used to avoid splitting a multi-byte rune across two
+// encoded-word tokens.
+func isUTF8Continuation(b byte) bool {
+	return b&0xC0 == 0x80
+}
+
+// encodeHeader returns s unchanged if it is plain ASCII, or one or more
+// RFC 2047 "=?UTF-8?B?...?=" encoded-words otherwise. s is split on
+// UTF-8 rune boundaries so that no single encoded-word token exceeds
+// rfc2047TokenLimit characters, and multiple tokens are folded onto
+// continuation lines with CRLF+SP, as required between adjacent
+// encoded-words.
+func encodeHeader(s string) string {
+	if !needsEncoding(s) {
+		return s
+	}
+
+	const prefix = "=?UTF-8?B?"
+	const suffix = "?="
+	maxEncodedText := rfc2047TokenLimit - len(prefix) - len(suffix)
+	rawChunkLen := (maxEncodedText / 4) * 3
+
+	data := []byte(s)
+	var tokens []string
+	for len(data) > 0 {
+		n := rawChunkLen
+		if n > len(data) {
+			n = len(data)
+		}
+		for n > 0 && n < len(data) && isUTF8Continuation(data[n]) {
+			n--
+		}
+		tokens = append(tokens, prefix+base64.StdEncoding.EncodeToString(data[:n])+suffix)
+		data = data[n:]
+	}
+
+	return strings.Join(tokens, "\r\n ")
+}
+
+// stripCRLF removes any bare or embedded CR/LF bytes from s. Header
+// values are written into the message verbatim after encoding, so any
+// CR or LF surviving to that point would let a crafted -t/-from value
+// inject extra header lines (or even a second message); every address
+// is scrubbed before it is inspected or encoded.
+func stripCRLF(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
+
+// splitAddressFields splits s on top-level commas, i.e. commas that
+// don't fall inside a quoted display name ("Last, First" <addr>). A
+// bare strings.Split would cut such a display name in half.
+func splitAddressFields(s string) []string {
+	var parts []string
+	var cur strings.Builder
+	inQuotes := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			inQuotes = !inQuotes
+			cur.WriteByte(c)
+		case c == ',' && !inQuotes:
+			parts = append(parts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	parts = append(parts, cur.String())
+	return parts
+}
+
+// encodeAddressList encodes the display-name portion of each
+// comma-separated address in s via encodeHeader, leaving the angle-bracket
+// address itself untouched.
+func encodeAddressList(s string) string {
+	parts := splitAddressFields(s)
+	for i, p := range parts {
+		parts[i] = encodeAddress(strings.TrimSpace(p))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// encodeAddress encodes the display-name portion of a single
+// "Display Name <user@host>" (or bare "user@host") address. The whole
+// address is scrubbed of CR/LF first (see stripCRLF) because the
+// bracketed "<...>" portion is copied into the header verbatim and
+// never passes through encodeHeader.
+func encodeAddress(addr string) string {
+	addr = stripCRLF(addr)
+
+	idx := strings.LastIndex(addr, "<")
+	if idx < 0 || !strings.HasSuffix(addr, ">") {
+		return encodeHeader(addr)
+	}
+
+	display := strings.TrimSpace(addr[:idx])
+	display = strings.Trim(display, "\"")
+	if display == "" || !needsEncoding(display) {
+		return addr
+	}
+
+	return encodeHeader(display) + " " + addr[idx:]
+}
+
+// rfc2231Safe reports whether b may appear literally in an RFC 2231
+// extended-parameter value without percent-encoding.
+func rfc2231Safe(b byte) bool {
+	switch {
+	case b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z', b >= '0' && b <= '9':
+		return true
+	}
+	switch b {
+	case '!', '#', '$', '&', '+', '-', '.', '^', '_', '`', '|', '~':
+		return true
+	}
+	return false
+}
+
+// encodeFilenameParam returns a Content-Type/Content-Disposition
+// parameter assignment for name. ASCII names are quoted normally
+// ("name=\"...\""); non-ASCII names use the RFC 2231 extended form
+// (name*=UTF-8''%xx...) so the original UTF-8 bytes survive.
+func encodeFilenameParam(param, name string) string {
+	if !needsEncoding(name) {
+		return param + "=\"" + name + "\""
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if rfc2231Safe(c) {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&0x0F])
+		}
+	}
+
+	return param + "*=UTF-8''" + b.String()
+}
@@ -2,12 +2,17 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"crypto/rand"
 	"encoding/base64"
 	"flag"
 	"fmt"
 	"io"
+	"net"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
 const (
@@ -60,6 +65,44 @@ func (l *lineBreaker) Write(p []byte) (n int, err error) {
 	return n, nil
 }
 
+// writePart writes a single MIME part: the delimiter line ("--" +
+// boundary, per RFC 2046), the given headers, a blank line, and finally
+// body encoded per encoding ("base64", "quoted-printable", or anything
+// else for a verbatim write). Every attachment funnels through here so
+// they share the same lineBreaker-wrapped base64 encoding; the text and
+// HTML body parts are written directly by main. boundary is the bare
+// value as declared in the enclosing
+// Content-Type header, without the delimiter's leading "--".
+func writePart(w *bufio.Writer, boundary string, headers []string, body []byte, encoding string) error {
+	w.WriteString("--")
+	w.WriteString(boundary)
+	w.WriteString(crlf)
+
+	for _, h := range headers {
+		w.WriteString(h)
+		w.WriteString(crlf)
+	}
+	w.WriteString(crlf)
+
+	switch encoding {
+	case "base64":
+		encoder := base64.NewEncoder(base64.StdEncoding, &lineBreaker{w: w})
+		if _, err := encoder.Write(body); err != nil {
+			return err
+		}
+		if err := encoder.Close(); err != nil {
+			return err
+		}
+	case "quoted-printable":
+		w.WriteString(quotedPrintableEncode(string(body)))
+	default:
+		w.Write(body)
+	}
+
+	w.WriteString(crlf)
+	return nil
+}
+
 func printUsage() {
 	programName := os.Args[0]
 	fmt.Fprintf(os.Stderr, "Usage: %s [OPTIONS] < input_image\r\n", programName)
@@ -68,10 +111,119 @@ func printUsage() {
 	fmt.Fprintf(os.Stderr, "  -t string    To: address (email recipient)\r\n")
 	fmt.Fprintf(os.Stderr, "  -s string    Subject: line\r\n")
 	fmt.Fprintf(os.Stderr, "  -n string    Newsgroups: (optional, for Usenet posts)\r\n")
+	fmt.Fprintf(os.Stderr, "  -b string    Plain text body (literal text, or a path to a file)\r\n")
+	fmt.Fprintf(os.Stderr, "  -H string    HTML body (literal text, or a path to a file)\r\n")
+	fmt.Fprintf(os.Stderr, "  -a path      Attach a file (repeatable)\r\n")
+	fmt.Fprintf(os.Stderr, "  -parse       Read an EML message from stdin and extract it instead of building one\r\n")
+	fmt.Fprintf(os.Stderr, "  -from string Envelope sender address (required for -smtp)\r\n")
+	fmt.Fprintf(os.Stderr, "  -smtp host:port  Submit the composed message via SMTP instead of printing it\r\n")
+	fmt.Fprintf(os.Stderr, "  -nntp host:port  Post the composed message via NNTP instead of printing it\r\n")
+	fmt.Fprintf(os.Stderr, "  -user string Username for SMTP/NNTP auth (falls back to ~/.netrc)\r\n")
+	fmt.Fprintf(os.Stderr, "  -pass string Password for SMTP/NNTP auth (falls back to ~/.netrc)\r\n")
 	fmt.Fprintf(os.Stderr, "  -h, --help   Show this help message\r\n\r\n")
 	fmt.Fprintf(os.Stderr, "Example:\r\n")
-	fmt.Fprintf(os.Stderr, "  %s -t recipient@example.com -s \"My Image\" < image.png > message.txt\r\n\r\n", programName)
-	fmt.Fprintf(os.Stderr, "The program reads from stdin and writes to stdout.\r\n")
+	fmt.Fprintf(os.Stderr, "  %s -t recipient@example.com -s \"My Image\" -a image.png > message.txt\r\n\r\n", programName)
+	fmt.Fprintf(os.Stderr, "If no -a flag is given, the program reads a single attachment from stdin.\r\n")
+	fmt.Fprintf(os.Stderr, "With -parse, it instead reads a composed message and reverses the process.\r\n")
+}
+
+// resolveBodyArg returns the body text for a -b/-H argument. If arg names
+// an existing, readable file, its contents are used; otherwise arg itself
+// is treated as the literal body text.
+func resolveBodyArg(arg string) (string, error) {
+	if arg == "" {
+		return "", nil
+	}
+	if info, err := os.Stat(arg); err == nil && !info.IsDir() {
+		data, err := os.ReadFile(arg)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+	return arg, nil
+}
+
+// extensionForType maps a sniffed content type to a plausible file
+// extension, used only when an attachment's real name is unknown (e.g.
+// the legacy stdin fallback, which has no path to take a name from).
+func extensionForType(contentType string) string {
+	switch contentType {
+	case "image/png":
+		return ".png"
+	case "image/jpeg":
+		return ".jpg"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	case "application/pdf":
+		return ".pdf"
+	default:
+		return ".bin"
+	}
+}
+
+// loadAttachments turns each -a path into an attachment, sniffing its
+// content type from the file's contents and extension. If paths is
+// empty, a single attachment is read from stdin instead (mip's original
+// image-on-stdin behavior), named randomly as before.
+func loadAttachments(paths []string) ([]attachment, error) {
+	if len(paths) == 0 {
+		stat, _ := os.Stdin.Stat()
+		if (stat.Mode() & os.ModeCharDevice) != 0 {
+			printUsage()
+			os.Exit(1)
+		}
+
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("reading stdin: %w", err)
+		}
+		if len(data) == 0 {
+			return nil, fmt.Errorf("input is empty")
+		}
+
+		contentType := detectContentType(data, "")
+		name, err := generateRandomString(filenameLen)
+		if err != nil {
+			return nil, fmt.Errorf("generating filename: %w", err)
+		}
+
+		return []attachment{{
+			filename:    name + extensionForType(contentType),
+			contentType: contentType,
+			data:        data,
+		}}, nil
+	}
+
+	attachments := make([]attachment, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading attachment %q: %w", path, err)
+		}
+		name := filepath.Base(path)
+		attachments = append(attachments, attachment{
+			filename:    name,
+			contentType: detectContentType(data, name),
+			data:        data,
+		})
+	}
+
+	return attachments, nil
+}
+
+// messageIDDomain returns the domain portion of from (stripping any
+// "Display Name <...>" wrapper) for use as the right-hand side of a
+// generated Message-ID, falling back to "localhost" if from has no
+// recognizable domain.
+func messageIDDomain(from string) string {
+	addr := addrOnly(from)
+	if i := strings.LastIndex(addr, "@"); i >= 0 && i+1 < len(addr) {
+		return addr[i+1:]
+	}
+	return "localhost"
 }
 
 func main() {
@@ -79,14 +231,24 @@ func main() {
 	to := flag.String("t", "", "To: address (email recipient)")
 	subject := flag.String("s", "", "Subject: line")
 	newsgroups := flag.String("n", "", "Newsgroups: (optional, for Usenet posts)")
+	body := flag.String("b", "", "Plain text body (literal text, or a path to a file)")
+	htmlBody := flag.String("H", "", "HTML body (literal text, or a path to a file)")
+	var attachPaths multiFlag
+	flag.Var(&attachPaths, "a", "Attach a file (repeatable)")
+	parseMode := flag.Bool("parse", false, "Read an EML message from stdin and extract it")
+	from := flag.String("from", "", "Envelope sender address (required for -smtp)")
+	smtpAddr := flag.String("smtp", "", "Submit the composed message via SMTP to host:port")
+	nntpAddr := flag.String("nntp", "", "Post the composed message via NNTP to host:port")
+	user := flag.String("user", "", "Username for SMTP/NNTP auth (falls back to ~/.netrc)")
+	pass := flag.String("pass", "", "Password for SMTP/NNTP auth (falls back to ~/.netrc)")
 	help := flag.Bool("h", false, "Show help")
 	helpLong := flag.Bool("help", false, "Show help")
-	
+
 	// Custom usage function
 	flag.Usage = func() {
 		printUsage()
 	}
-	
+
 	flag.Parse()
 
 	// Check for help flag
@@ -95,44 +257,21 @@ func main() {
 		os.Exit(0)
 	}
 
-	// Check if stdin has data (not a terminal)
-	stat, _ := os.Stdin.Stat()
-	if (stat.Mode() & os.ModeCharDevice) != 0 {
-		printUsage()
-		os.Exit(1)
+	if *parseMode {
+		if err := runParse(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\r\n", err)
+			os.Exit(1)
+		}
+		return
 	}
 
-	// Read entire input from stdin
-	data, err := io.ReadAll(os.Stdin)
+	attachments, err := loadAttachments(attachPaths)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading input: %v\r\n", err)
-		os.Exit(1)
-	}
-
-	if len(data) == 0 {
-		fmt.Fprintf(os.Stderr, "Error: Input is empty\r\n")
+		fmt.Fprintf(os.Stderr, "Error: %v\r\n", err)
 		os.Exit(1)
 	}
 
-	// Detect file type based on magic numbers
-	var contentType, extension string
-	
-	// PNG signature: 89 50 4E 47 0D 0A 1A 0A
-	if len(data) >= 8 && data[0] == 0x89 && data[1] == 0x50 && data[2] == 0x4E && 
-		data[3] == 0x47 && data[4] == 0x0D && data[5] == 0x0A && 
-		data[6] == 0x1A && data[7] == 0x0A {
-		contentType = "image/png"
-		extension = ".png"
-	} else if len(data) >= 2 && data[0] == 0xFF && data[1] == 0xD8 {
-		// JPEG/JFIF signature starts with FF D8
-		contentType = "image/jpeg"
-		extension = ".jpg"
-	} else {
-		fmt.Fprintf(os.Stderr, "Error: File type not recognized. Only PNG and JPEG are supported.\r\n")
-		os.Exit(1)
-	}
-
-	// Generate random strings for boundary and filename
+	// Generate a random boundary for the outer multipart/mixed body
 	boundarySuffix, err := generateRandomString(boundaryLen)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error generating boundary: %v\r\n", err)
@@ -142,34 +281,80 @@ func main() {
 	// Complete boundary = 14 dashes + random string
 	fullBoundary := dashes + boundarySuffix
 
-	filename, err := generateRandomString(filenameLen)
+	// Resolve the text/HTML body arguments, each of which may be literal
+	// text or a path to a file containing the body.
+	plainText, err := resolveBodyArg(*body)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading text body: %v\r\n", err)
+		os.Exit(1)
+	}
+	if plainText == "" {
+		plainText = "(Your message goes here.)"
+	}
+
+	htmlText, err := resolveBodyArg(*htmlBody)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error generating filename: %v\r\n", err)
+		fmt.Fprintf(os.Stderr, "Error reading HTML body: %v\r\n", err)
 		os.Exit(1)
 	}
 
-	fullFilename := filename + extension
+	var altBoundary string
+	if htmlText != "" {
+		altBoundarySuffix, err := generateRandomString(boundaryLen)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating boundary: %v\r\n", err)
+			os.Exit(1)
+		}
+		altBoundary = dashes + altBoundarySuffix
+	}
 
-	// Write headers and encoded data to stdout
-	writer := bufio.NewWriter(os.Stdout)
-	defer writer.Flush()
+	// Compose the message into a buffer; it feeds stdout, SMTP, or NNTP
+	// depending on which transport flag (if any) was given.
+	var msgBuf bytes.Buffer
+	writer := bufio.NewWriter(&msgBuf)
 
 	// Write headers with CRLF line endings (RFC 822/2822 requirement)
 	
+	// From: header (write only if -from was given; RFC 5322 requires
+	// exactly one, and without it most MTAs will reject or spam-flag
+	// a message submitted via -smtp/-nntp). Date and Message-ID are
+	// likewise required and are generated alongside it.
+	if *from != "" {
+		writer.WriteString("From: ")
+		writer.WriteString(encodeAddress(*from))
+		writer.WriteString(crlf)
+
+		writer.WriteString("Date: ")
+		writer.WriteString(time.Now().Format(time.RFC1123Z))
+		writer.WriteString(crlf)
+
+		msgIDSuffix, err := generateRandomString(filenameLen)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating Message-ID: %v\r\n", err)
+			os.Exit(1)
+		}
+		writer.WriteString("Message-ID: <")
+		writer.WriteString(msgIDSuffix)
+		writer.WriteString("@")
+		writer.WriteString(messageIDDomain(*from))
+		writer.WriteString(">")
+		writer.WriteString(crlf)
+	}
+
 	// To: header (always write, even if empty for Usenet)
 	writer.WriteString("To: ")
-	writer.WriteString(*to)
+	writer.WriteString(encodeAddressList(*to))
 	writer.WriteString(crlf)
 
 	// Subject: header (always write, even if empty)
 	writer.WriteString("Subject: ")
-	writer.WriteString(*subject)
+	writer.WriteString(encodeHeader(*subject))
 	writer.WriteString(crlf)
 
 	// Newsgroups: header (write only if provided)
 	if *newsgroups != "" {
 		writer.WriteString("Newsgroups: ")
-		writer.WriteString(*newsgroups)
+		writer.WriteString(stripCRLF(*newsgroups))
 		writer.WriteString(crlf)
 	}
 
@@ -190,57 +375,114 @@ func main() {
 	writer.WriteString(crlf)
 	
 	// First boundary (text part) - with 14 dashes
+	writer.WriteString("--")
 	writer.WriteString(fullBoundary)
 	writer.WriteString(crlf)
-	
-	// Text part headers
-	writer.WriteString("Content-Type: text/plain; charset=UTF-8; format=flowed")
-	writer.WriteString(crlf)
-	writer.WriteString("Content-Transfer-Encoding: 7bit")
-	writer.WriteString(crlf)
-	
-	// Blank line before text content
-	writer.WriteString(crlf)
-	
-	// Text content with placeholder
-	writer.WriteString("(Your message goes here.)")
-	writer.WriteString(crlf)
-	
-	// Second boundary (image part) - with 14 dashes
-	writer.WriteString(fullBoundary)
-	writer.WriteString(crlf)
-	
-	// Image part headers
-	writer.WriteString("Content-Type: ")
-	writer.WriteString(contentType)
-	writer.WriteString("; name=\"")
-	writer.WriteString(fullFilename)
-	writer.WriteString("\"")
-	writer.WriteString(crlf)
-	
-	writer.WriteString("Content-Disposition: attachment; filename=\"")
-	writer.WriteString(fullFilename)
-	writer.WriteString("\"")
-	writer.WriteString(crlf)
-	
-	writer.WriteString("Content-Transfer-Encoding: base64")
-	writer.WriteString(crlf)
-	
-	// Blank line before base64 data
-	writer.WriteString(crlf)
 
-	// Encode image data to base64 with line wrapping
-	encoder := base64.NewEncoder(base64.StdEncoding, &lineBreaker{w: writer})
-	_, err = encoder.Write(data)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error during base64 encoding: %v\r\n", err)
-		os.Exit(1)
+	if htmlText != "" {
+		// Nested multipart/alternative carrying both the plain-text and
+		// HTML renditions of the body.
+		writer.WriteString("Content-Type: multipart/alternative; boundary=\"")
+		writer.WriteString(altBoundary)
+		writer.WriteString("\"")
+		writer.WriteString(crlf)
+		writer.WriteString(crlf)
+
+		writer.WriteString("--")
+		writer.WriteString(altBoundary)
+		writer.WriteString(crlf)
+		writer.WriteString("Content-Type: text/plain; charset=UTF-8")
+		writer.WriteString(crlf)
+		writer.WriteString("Content-Transfer-Encoding: quoted-printable")
+		writer.WriteString(crlf)
+		writer.WriteString(crlf)
+		writer.WriteString(quotedPrintableEncode(plainText))
+		writer.WriteString(crlf)
+
+		writer.WriteString("--")
+		writer.WriteString(altBoundary)
+		writer.WriteString(crlf)
+		writer.WriteString("Content-Type: text/html; charset=UTF-8")
+		writer.WriteString(crlf)
+		writer.WriteString("Content-Transfer-Encoding: quoted-printable")
+		writer.WriteString(crlf)
+		writer.WriteString(crlf)
+		writer.WriteString(quotedPrintableEncode(htmlText))
+		writer.WriteString(crlf)
+
+		writer.WriteString("--")
+		writer.WriteString(altBoundary)
+		writer.WriteString("--")
+		writer.WriteString(crlf)
+	} else {
+		// Text part headers
+		writer.WriteString("Content-Type: text/plain; charset=UTF-8; format=flowed")
+		writer.WriteString(crlf)
+		writer.WriteString("Content-Transfer-Encoding: quoted-printable")
+		writer.WriteString(crlf)
+
+		// Blank line before text content
+		writer.WriteString(crlf)
+
+		writer.WriteString(quotedPrintableEncode(plainText))
+		writer.WriteString(crlf)
+	}
+
+	// One boundary + part per attachment, each base64-encoded.
+	for _, att := range attachments {
+		headers := []string{
+			"Content-Type: " + att.contentType + "; " + encodeFilenameParam("name", att.filename),
+			"Content-Disposition: attachment; " + encodeFilenameParam("filename", att.filename),
+			"Content-Transfer-Encoding: base64",
+		}
+		if err := writePart(writer, fullBoundary, headers, att.data, "base64"); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing attachment %q: %v\r\n", att.filename, err)
+			os.Exit(1)
+		}
 	}
-	encoder.Close()
 
 	// Final boundary to end the multipart message - with 14 dashes and two extra dashes
-	writer.WriteString(crlf)
+	writer.WriteString("--")
 	writer.WriteString(fullBoundary)
 	writer.WriteString("--")
 	writer.WriteString(crlf)
+
+	if err := writer.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error composing message: %v\r\n", err)
+		os.Exit(1)
+	}
+
+	switch {
+	case *smtpAddr != "":
+		authUser, authPass := *user, *pass
+		if authUser == "" {
+			host, _, _ := net.SplitHostPort(*smtpAddr)
+			if netUser, netPass, ok := netrcCredentials(host); ok {
+				authUser, authPass = netUser, netPass
+			}
+		}
+		recipients := splitAddressList(*to)
+		if *from == "" || len(recipients) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: -smtp requires -from and at least one -t recipient\r\n")
+			os.Exit(1)
+		}
+		if err := sendSMTP(*smtpAddr, authUser, authPass, addrOnly(*from), recipients, msgBuf.Bytes(), os.Stderr); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: SMTP submission failed: %v\r\n", err)
+			os.Exit(1)
+		}
+	case *nntpAddr != "":
+		authUser, authPass := *user, *pass
+		if authUser == "" {
+			host, _, _ := net.SplitHostPort(*nntpAddr)
+			if netUser, netPass, ok := netrcCredentials(host); ok {
+				authUser, authPass = netUser, netPass
+			}
+		}
+		if err := sendNNTP(*nntpAddr, authUser, authPass, msgBuf.Bytes(), os.Stderr); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: NNTP submission failed: %v\r\n", err)
+			os.Exit(1)
+		}
+	default:
+		os.Stdout.Write(msgBuf.Bytes())
+	}
 }
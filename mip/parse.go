@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// headerGetter is implemented by both net/mail.Header (the top-level
+// message) and textproto.MIMEHeader (every multipart.Part), letting
+// walkPart treat them the same way.
+type headerGetter interface {
+	Get(key string) string
+}
+
+// runParse reads an EML message from r, prints a structured summary of
+// its MIME tree to out, and extracts every non-text or attachment part
+// into a freshly created directory (see makeExtractDir).
+func runParse(r io.Reader, out io.Writer) error {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return fmt.Errorf("parsing message: %w", err)
+	}
+
+	dec := new(mime.WordDecoder)
+	decodeHeader := func(key string) string {
+		v := msg.Header.Get(key)
+		if d, err := dec.DecodeHeader(v); err == nil {
+			return d
+		}
+		return v
+	}
+
+	fmt.Fprintf(out, "From: %s\r\n", decodeHeader("From"))
+	fmt.Fprintf(out, "To: %s\r\n", decodeHeader("To"))
+	fmt.Fprintf(out, "Subject: %s\r\n", decodeHeader("Subject"))
+	fmt.Fprintf(out, "Date: %s\r\n", decodeHeader("Date"))
+	fmt.Fprintln(out)
+
+	outDir, err := makeExtractDir()
+	if err != nil {
+		return fmt.Errorf("creating output directory: %w", err)
+	}
+	fmt.Fprintf(out, "Extracting attachments to %s\r\n\r\n", outDir)
+
+	return walkPart(msg.Header, msg.Body, 0, outDir, out)
+}
+
+// makeExtractDir creates a fresh directory (named with the same random
+// string scheme mip uses for boundaries and filenames) to hold a single
+// -parse run's extracted attachments.
+func makeExtractDir() (string, error) {
+	suffix, err := generateRandomString(filenameLen)
+	if err != nil {
+		return "", err
+	}
+	dir := "mip-parse-" + suffix
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// walkPart recursively decodes a MIME part. multipart/mixed,
+// multipart/alternative, and multipart/related bodies are descended
+// into; text parts not marked as attachments (see isAttachmentPart) are
+// printed inline; everything else is decoded and written to outDir.
+func walkPart(h headerGetter, body io.Reader, depth int, outDir string, out io.Writer) error {
+	indent := strings.Repeat("  ", depth)
+
+	contentType := h.Get("Content-Type")
+	if contentType == "" {
+		contentType = "text/plain; charset=us-ascii"
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("parsing Content-Type %q: %w", contentType, err)
+	}
+
+	decoded, err := decodeTransferEncoding(body, h.Get("Content-Transfer-Encoding"))
+	if err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		fmt.Fprintf(out, "%s[%s]\r\n", indent, mediaType)
+		mr := multipart.NewReader(decoded, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("reading %s part: %w", mediaType, err)
+			}
+			if err := walkPart(part.Header, part, depth+1, outDir, out); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	data, err := io.ReadAll(decoded)
+	if err != nil {
+		return fmt.Errorf("reading %s part: %w", mediaType, err)
+	}
+
+	if strings.HasPrefix(mediaType, "text/") && !isAttachmentPart(h) {
+		fmt.Fprintf(out, "%s[%s] %d bytes\r\n", indent, mediaType, len(data))
+		fmt.Fprintf(out, "%s%s\r\n", indent, string(data))
+		return nil
+	}
+
+	name, err := attachmentFilename(h, mediaType)
+	if err != nil {
+		return err
+	}
+	outPath := uniqueOutputPath(outDir, name, out)
+	if err := os.WriteFile(outPath, data, 0o644); err != nil {
+		return fmt.Errorf("writing %s: %w", outPath, err)
+	}
+	fmt.Fprintf(out, "%s[%s] -> %s (%d bytes)\r\n", indent, mediaType, outPath, len(data))
+	return nil
+}
+
+// uniqueOutputPath joins outDir and name, and, if that path is already
+// taken by an earlier part in this run (e.g. two attachments sharing a
+// basename), disambiguates it with a "-2", "-3", ... suffix before the
+// extension, warning on out so the collision isn't silently lost.
+func uniqueOutputPath(outDir, name string, out io.Writer) string {
+	outPath := filepath.Join(outDir, name)
+	if _, err := os.Stat(outPath); os.IsNotExist(err) {
+		return outPath
+	}
+
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 2; ; i++ {
+		candidate := filepath.Join(outDir, fmt.Sprintf("%s-%d%s", base, i, ext))
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			fmt.Fprintf(out, "warning: %q collides with an already-extracted attachment; writing %s instead\r\n", name, filepath.Base(candidate))
+			return candidate
+		}
+	}
+}
+
+// decodeTransferEncoding wraps body with the io.Reader matching
+// encoding ("base64" or "quoted-printable"); any other value (including
+// "7bit"/"8bit"/"") is passed through unchanged.
+func decodeTransferEncoding(body io.Reader, encoding string) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body), nil
+	case "quoted-printable":
+		return quotedprintable.NewReader(body), nil
+	default:
+		return body, nil
+	}
+}
+
+// isAttachmentPart reports whether h marks its part as an attachment
+// rather than inline body text: either an explicit
+// "Content-Disposition: attachment", a Content-Disposition "filename"
+// param, or a Content-Type "name" param (mip itself sets the latter
+// for text/* attachments, e.g. a .txt file sent via -a).
+func isAttachmentPart(h headerGetter) bool {
+	if cd := h.Get("Content-Disposition"); cd != "" {
+		if dtype, params, err := mime.ParseMediaType(cd); err == nil {
+			if strings.EqualFold(dtype, "attachment") || params["filename"] != "" {
+				return true
+			}
+		}
+	}
+	if ct := h.Get("Content-Type"); ct != "" {
+		if _, params, err := mime.ParseMediaType(ct); err == nil && params["name"] != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// attachmentFilename resolves the filename for a non-text part from its
+// Content-Disposition (or Content-Type "name") parameter, decoding any
+// RFC 2047 encoded-word it contains. If no filename is given, a random
+// one is generated using the part's content type to pick an extension.
+func attachmentFilename(h headerGetter, mediaType string) (string, error) {
+	dec := new(mime.WordDecoder)
+
+	if cd := h.Get("Content-Disposition"); cd != "" {
+		if _, params, err := mime.ParseMediaType(cd); err == nil && params["filename"] != "" {
+			if name, err := dec.DecodeHeader(params["filename"]); err == nil {
+				return filepath.Base(name), nil
+			}
+			return filepath.Base(params["filename"]), nil
+		}
+	}
+
+	if ct := h.Get("Content-Type"); ct != "" {
+		if _, params, err := mime.ParseMediaType(ct); err == nil && params["name"] != "" {
+			if name, err := dec.DecodeHeader(params["name"]); err == nil {
+				return filepath.Base(name), nil
+			}
+			return filepath.Base(params["name"]), nil
+		}
+	}
+
+	suffix, err := generateRandomString(filenameLen)
+	if err != nil {
+		return "", err
+	}
+	ext := ".bin"
+	if exts, err := mime.ExtensionsByType(mediaType); err == nil && len(exts) > 0 {
+		ext = exts[0]
+	}
+	return suffix + ext, nil
+}
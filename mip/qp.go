@@ -0,0 +1,49 @@
+package main
+
+import "strings"
+
+// qpLineLimit is the maximum encoded line length before a soft break is
+// inserted (RFC 2045 recommends lines no longer than 76 characters).
+const qpLineLimit = 76
+
+const hexDigits = "0123456789ABCDEF"
+
+// quotedPrintableEncode encodes s per RFC 2045 quoted-printable rules.
+// Bytes outside the printable ASCII range (0x21-0x7E), along with '=',
+// are escaped as "=XX" hex sequences. Lines are wrapped at qpLineLimit
+// characters using a soft line break ("=\r\n"), and existing CRLF
+// boundaries in s are preserved rather than folded into the encoding.
+func quotedPrintableEncode(s string) string {
+	lines := strings.Split(s, "\r\n")
+	for i, line := range lines {
+		lines[i] = encodeQPLine(line)
+	}
+	return strings.Join(lines, "\r\n")
+}
+
+// encodeQPLine quoted-printable encodes a single line (no CR/LF) and
+// inserts soft line breaks so no output line exceeds qpLineLimit.
+func encodeQPLine(line string) string {
+	var b strings.Builder
+	lineLen := 0
+
+	writeToken := func(tok string) {
+		if lineLen+len(tok) > qpLineLimit-1 {
+			b.WriteString("=\r\n")
+			lineLen = 0
+		}
+		b.WriteString(tok)
+		lineLen += len(tok)
+	}
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if c == '=' || c < 0x21 || c > 0x7E {
+			writeToken(string([]byte{'=', hexDigits[c>>4], hexDigits[c&0x0F]}))
+			continue
+		}
+		writeToken(string(c))
+	}
+
+	return b.String()
+}
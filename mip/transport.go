@@ -0,0 +1,230 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// addrOnly strips a "Display Name <user@host>" wrapper down to the bare
+// user@host, for use as an SMTP RCPT TO / MAIL FROM argument.
+func addrOnly(addr string) string {
+	addr = strings.TrimSpace(addr)
+	if i := strings.LastIndex(addr, "<"); i >= 0 && strings.HasSuffix(addr, ">") {
+		return addr[i+1 : len(addr)-1]
+	}
+	return addr
+}
+
+// splitAddressList splits a comma-separated address list into bare
+// user@host addresses, respecting quoted display names (see
+// splitAddressFields) so a "Last, First" <addr> mailbox isn't cut in half.
+func splitAddressList(s string) []string {
+	var addrs []string
+	for _, p := range splitAddressFields(s) {
+		if a := addrOnly(p); a != "" {
+			addrs = append(addrs, a)
+		}
+	}
+	return addrs
+}
+
+// netrcCredentials looks up the login/password for machine in
+// ~/.netrc, returning ok=false if no matching entry is found.
+func netrcCredentials(machine string) (user, pass string, ok bool) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", "", false
+	}
+
+	data, err := os.ReadFile(filepath.Join(home, ".netrc"))
+	if err != nil {
+		return "", "", false
+	}
+
+	fields := strings.Fields(string(data))
+	matched := false
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "machine":
+			if i+1 < len(fields) {
+				matched = fields[i+1] == machine
+			}
+		case "login":
+			if matched && i+1 < len(fields) {
+				user = fields[i+1]
+			}
+		case "password":
+			if matched && i+1 < len(fields) {
+				pass = fields[i+1]
+				ok = true
+			}
+		}
+	}
+	return user, pass, ok
+}
+
+// loginAuth implements the SMTP LOGIN authentication mechanism, which
+// net/smtp does not provide (it only ships PLAIN and CRAM-MD5).
+type loginAuth struct {
+	username, password string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(string(fromServer)) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected LOGIN prompt: %q", fromServer)
+	}
+}
+
+// sendSMTP submits msg over addr ("host:port"), upgrading to TLS via
+// STARTTLS when the server offers it and authenticating with user/pass
+// (PLAIN or LOGIN, whichever the server advertises) when non-empty.
+// Per-recipient acceptance is reported to out.
+func sendSMTP(addr, user, pass, from string, recipients []string, msg []byte, out io.Writer) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid -smtp address %q: %w", addr, err)
+	}
+
+	c, err := smtp.Dial(addr)
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", addr, err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("STARTTLS: %w", err)
+		}
+	}
+
+	if user != "" {
+		if ok, mechanisms := c.Extension("AUTH"); ok {
+			var auth smtp.Auth
+			switch {
+			case strings.Contains(mechanisms, "PLAIN"):
+				auth = smtp.PlainAuth("", user, pass, host)
+			case strings.Contains(mechanisms, "LOGIN"):
+				auth = &loginAuth{username: user, password: pass}
+			}
+			if auth != nil {
+				if err := c.Auth(auth); err != nil {
+					return fmt.Errorf("AUTH: %w", err)
+				}
+			}
+		}
+	}
+
+	if err := c.Mail(from); err != nil {
+		return fmt.Errorf("MAIL FROM: %w", err)
+	}
+
+	var failed []string
+	for _, rcpt := range recipients {
+		if err := c.Rcpt(rcpt); err != nil {
+			fmt.Fprintf(out, "%s: rejected: %v\r\n", rcpt, err)
+			failed = append(failed, rcpt)
+			continue
+		}
+		fmt.Fprintf(out, "%s: accepted\r\n", rcpt)
+	}
+	if len(failed) == len(recipients) {
+		return errors.New("all recipients rejected")
+	}
+
+	wc, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("DATA: %w", err)
+	}
+	if _, err := wc.Write(msg); err != nil {
+		return fmt.Errorf("writing message body: %w", err)
+	}
+	if err := wc.Close(); err != nil {
+		return fmt.Errorf("completing DATA: %w", err)
+	}
+
+	return c.Quit()
+}
+
+// sendNNTP posts msg to addr ("host:port") via MODE READER, optional
+// AUTHINFO USER/PASS, and POST, dot-stuffing the body as required.
+func sendNNTP(addr, user, pass string, msg []byte, out io.Writer) error {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", addr, err)
+	}
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+
+	if code, _, err := tp.ReadCodeLine(200); err != nil && code != 201 {
+		return fmt.Errorf("reading greeting: %w", err)
+	}
+
+	if err := tp.PrintfLine("MODE READER"); err != nil {
+		return fmt.Errorf("MODE READER: %w", err)
+	}
+	if code, _, err := tp.ReadCodeLine(200); err != nil && code != 201 {
+		return fmt.Errorf("MODE READER: %w", err)
+	}
+
+	if user != "" {
+		if err := tp.PrintfLine("AUTHINFO USER %s", user); err != nil {
+			return fmt.Errorf("AUTHINFO USER: %w", err)
+		}
+		if code, _, err := tp.ReadCodeLine(381); err == nil {
+			if err := tp.PrintfLine("AUTHINFO PASS %s", pass); err != nil {
+				return fmt.Errorf("AUTHINFO PASS: %w", err)
+			}
+			if _, _, err := tp.ReadCodeLine(281); err != nil {
+				return fmt.Errorf("AUTHINFO PASS: %w", err)
+			}
+		} else if code != 281 {
+			return fmt.Errorf("AUTHINFO USER: %w", err)
+		}
+	}
+
+	if err := tp.PrintfLine("POST"); err != nil {
+		return fmt.Errorf("POST: %w", err)
+	}
+	if _, _, err := tp.ReadCodeLine(340); err != nil {
+		return fmt.Errorf("POST not permitted: %w", err)
+	}
+
+	dw := tp.DotWriter()
+	if _, err := dw.Write(msg); err != nil {
+		return fmt.Errorf("writing article: %w", err)
+	}
+	if err := dw.Close(); err != nil {
+		return fmt.Errorf("writing article: %w", err)
+	}
+
+	_, articleStatus, err := tp.ReadCodeLine(240)
+	if err != nil {
+		fmt.Fprintf(out, "post rejected: %s\r\n", articleStatus)
+		return fmt.Errorf("article not accepted: %w", err)
+	}
+	fmt.Fprintf(out, "post accepted: %s\r\n", articleStatus)
+
+	tp.PrintfLine("QUIT")
+	return nil
+}